@@ -0,0 +1,213 @@
+package connection
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect hides the SQL-flavor differences between the database engines
+// wreck-it can pivot against behind a single interface, so the rest of
+// the pivot/executor layers never have to special-case a driver name.
+type Dialect interface {
+	// Name returns the driver name as accepted by Config.Driver.
+	Name() string
+
+	// QuoteIdent quotes a single identifier (table or column name) the
+	// way the target engine expects it.
+	QuoteIdent(ident string) string
+
+	// FetchTablesSQL returns the statement used to enumerate the base
+	// tables of dbName.
+	FetchTablesSQL(dbName string) string
+
+	// FetchColumnsSQL returns the statement used to describe the
+	// columns of dbName.table.
+	FetchColumnsSQL(dbName, table string) string
+
+	// FetchIndexesSQL returns the statement used to enumerate the
+	// indexes defined on dbName.table.
+	FetchIndexesSQL(dbName, table string) string
+
+	// IndexNameColumn is the result-set column holding the index name
+	// for the rows returned by FetchIndexesSQL.
+	IndexNameColumn() string
+
+	// RandomRowLimit returns a `SELECT * FROM <table> ORDER BY
+	// <random-fn> LIMIT 1`-style statement used to pick a pivot row.
+	RandomRowLimit(table string) string
+
+	// OrderByTerm renders a single ORDER BY term for col sorted dir
+	// ("ASC"/"DESC") with NULLs placed first or last, using whichever
+	// syntax the engine actually supports for NULL ordering.
+	OrderByTerm(col, dir string, nullsFirst bool) string
+
+	// TernaryPredicate returns a side-effect-free boolean expression
+	// over ref (a qualified column reference) that is well-typed
+	// regardless of the column's SQL type, and that genuinely exercises
+	// all three truth values: true for some rows, false for others, and
+	// NULL exactly when ref itself is NULL. Callers that only have a
+	// column name (no type information) use this instead of
+	// hand-rolling a comparison like "<col> > 0" (a planning-time type
+	// error on engines that don't coerce text to numeric) or "<col> =
+	// <col>" (a tautology that can never be false, so TLP's NOT-p
+	// partition would always come back empty).
+	TernaryPredicate(ref string) string
+
+	// LiteralEquals returns a side-effect-free boolean expression testing
+	// whether ref equals literal (a quoted SQL string literal), casting
+	// ref to the engine's text type first so the comparison type-checks
+	// regardless of ref's actual column type. Callers that only have a
+	// column name (no type information) use this instead of comparing
+	// ref to literal directly, which can fail to parse/plan on columns
+	// whose type doesn't accept a bare string literal (e.g. bit, bytea).
+	LiteralEquals(ref, literal string) string
+}
+
+// MySQLDialect speaks MySQL/TiDB flavored SQL. It is the dialect
+// wreck-it has always used, lifted out of the hard-coded strings that
+// used to live in pivot.go.
+type MySQLDialect struct{}
+
+func (MySQLDialect) Name() string { return "mysql" }
+
+func (MySQLDialect) QuoteIdent(ident string) string {
+	return "`" + ident + "`"
+}
+
+func (MySQLDialect) FetchTablesSQL(dbName string) string {
+	return fmt.Sprintf(
+		"SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_TYPE FROM information_schema.tables WHERE TABLE_SCHEMA = '%s'",
+		dbName,
+	)
+}
+
+func (MySQLDialect) FetchColumnsSQL(dbName, table string) string {
+	return fmt.Sprintf("DESC %s.%s", dbName, table)
+}
+
+func (MySQLDialect) FetchIndexesSQL(dbName, table string) string {
+	return fmt.Sprintf(
+		"SELECT DISTINCT INDEX_NAME FROM information_schema.statistics WHERE TABLE_SCHEMA = '%s' AND TABLE_NAME = '%s'",
+		dbName, table,
+	)
+}
+
+func (MySQLDialect) IndexNameColumn() string { return "INDEX_NAME" }
+
+func (MySQLDialect) RandomRowLimit(table string) string {
+	return fmt.Sprintf("SELECT * FROM %s ORDER BY RAND() LIMIT 1;", table)
+}
+
+// TernaryPredicate casts ref to CHAR (well-typed for any column type)
+// and tests the parity of its rendered length: true or false depending
+// on the actual value, NULL when ref is NULL, since CAST/CHAR_LENGTH/MOD
+// all propagate NULL through untouched.
+func (MySQLDialect) TernaryPredicate(ref string) string {
+	return fmt.Sprintf("MOD(CHAR_LENGTH(CAST(%s AS CHAR)), 2) = 0", ref)
+}
+
+// LiteralEquals casts ref to CHAR before comparing, the same way
+// TernaryPredicate does, so the comparison is well-typed regardless of
+// ref's actual column type.
+func (MySQLDialect) LiteralEquals(ref, literal string) string {
+	return fmt.Sprintf("CAST(%s AS CHAR) = %s", ref, literal)
+}
+
+// OrderByTerm works around MySQL/TiDB not supporting NULLS FIRST/LAST:
+// sorting by "<col> IS NULL" first (DESC to put NULLs first, ASC to put
+// them last) ranks all NULLs together at the requested end before col
+// itself breaks ties among the non-NULL rows.
+func (MySQLDialect) OrderByTerm(col, dir string, nullsFirst bool) string {
+	nullRank := "ASC"
+	if nullsFirst {
+		nullRank = "DESC"
+	}
+	return fmt.Sprintf("(%s IS NULL) %s, %s %s", col, nullRank, col, dir)
+}
+
+// PostgresDialect speaks PostgreSQL flavored SQL, introspecting schema
+// through information_schema/pg_indexes instead of MySQL's SHOW
+// statements.
+type PostgresDialect struct{}
+
+func (PostgresDialect) Name() string { return "postgres" }
+
+func (PostgresDialect) QuoteIdent(ident string) string {
+	return `"` + ident + `"`
+}
+
+func (PostgresDialect) FetchTablesSQL(dbName string) string {
+	return fmt.Sprintf(
+		"SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_TYPE FROM information_schema.tables WHERE TABLE_SCHEMA = '%s'",
+		dbName,
+	)
+}
+
+func (PostgresDialect) FetchColumnsSQL(dbName, table string) string {
+	return fmt.Sprintf(
+		"SELECT column_name, data_type, is_nullable FROM information_schema.columns WHERE table_schema = '%s' AND table_name = '%s'",
+		dbName, table,
+	)
+}
+
+func (PostgresDialect) FetchIndexesSQL(dbName, table string) string {
+	return fmt.Sprintf(
+		"SELECT indexname FROM pg_indexes WHERE schemaname = '%s' AND tablename = '%s'",
+		dbName, table,
+	)
+}
+
+func (PostgresDialect) IndexNameColumn() string { return "indexname" }
+
+func (PostgresDialect) RandomRowLimit(table string) string {
+	return fmt.Sprintf("SELECT * FROM %s ORDER BY random() LIMIT 1;", table)
+}
+
+// TernaryPredicate casts ref to TEXT (well-typed for any column type)
+// and tests the parity of its rendered length: true or false depending
+// on the actual value, NULL when ref is NULL, since the cast/length/mod
+// all propagate NULL through untouched.
+func (PostgresDialect) TernaryPredicate(ref string) string {
+	return fmt.Sprintf("length(CAST(%s AS TEXT)) %% 2 = 0", ref)
+}
+
+// LiteralEquals casts ref to TEXT before comparing, the same way
+// TernaryPredicate does, so the comparison is well-typed regardless of
+// ref's actual column type.
+func (PostgresDialect) LiteralEquals(ref, literal string) string {
+	return fmt.Sprintf("CAST(%s AS TEXT) = %s", ref, literal)
+}
+
+func (PostgresDialect) OrderByTerm(col, dir string, nullsFirst bool) string {
+	nullsKw := "NULLS LAST"
+	if nullsFirst {
+		nullsKw = "NULLS FIRST"
+	}
+	return fmt.Sprintf("%s %s %s", col, dir, nullsKw)
+}
+
+// InferDriver guesses a driver from dsn's URL scheme, for callers that
+// want Config.Driver's "inferred from Dsn when empty" behavior: a
+// "postgres://" or "postgresql://" DSN resolves to "postgres", and
+// everything else - including MySQL/TiDB's schemeless
+// "user:pass@tcp(host:port)/db" form - defaults to "mysql".
+func InferDriver(dsn string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return "postgres"
+	}
+	return "mysql"
+}
+
+// DialectFor returns the Dialect registered for driver, defaulting to
+// MySQLDialect when driver is empty so existing callers keep their
+// current behavior.
+func DialectFor(driver string) (Dialect, error) {
+	switch driver {
+	case "", "mysql":
+		return MySQLDialect{}, nil
+	case "postgres":
+		return PostgresDialect{}, nil
+	default:
+		return nil, fmt.Errorf("connection: unsupported driver %q", driver)
+	}
+}
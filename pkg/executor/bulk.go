@@ -0,0 +1,211 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pingcap/parser/model"
+)
+
+// BulkInsert loads rows into table as efficiently as the underlying
+// driver allows: on PostgreSQL it streams rows through COPY inside a
+// single transaction, everywhere else it falls back to batched
+// multi-row INSERT statements sized by batchSize.
+func (e *Executor) BulkInsert(table string, columns []string, rows [][]string, batchSize int) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if e.Dialect.Name() == "postgres" {
+		return e.copyInsert(table, columns, rows)
+	}
+	return e.batchInsert(table, columns, rows, batchSize)
+}
+
+func (e *Executor) copyInsert(table string, columns []string, rows [][]string) error {
+	tx, err := e.DB.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(pq.CopyIn(table, columns...))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, row := range rows {
+		args := make([]interface{}, len(row))
+		for i, v := range row {
+			args[i] = copyArg(v)
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			stmt.Close()
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		tx.Rollback()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// copyArg converts one literal value out of generateInsertRowValues'
+// column/value split - SQL text meant for a textual INSERT statement,
+// so a string value still carries its surrounding quotes and NULL is
+// spelled out as the literal token "NULL" - into the raw value COPY
+// expects: CopyIn sends each arg as the column's actual value, not a
+// SQL expression, so handing it "'x'" verbatim would store the two
+// quote characters as part of the string instead of stripping them.
+func copyArg(literal string) interface{} {
+	if strings.EqualFold(literal, "NULL") {
+		return nil
+	}
+	if len(literal) >= 2 && literal[0] == '\'' && literal[len(literal)-1] == '\'' {
+		return unescapeSQLString(literal[1 : len(literal)-1])
+	}
+	return literal
+}
+
+// unescapeSQLString undoes both quote-escaping styles splitSQLList
+// tolerates when splitting a value tuple - a backslash-escaped quote
+// (MySQL/TiDB's default) and a doubled quote (the standard SQL style) -
+// in a single left-to-right pass.
+func unescapeSQLString(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		case c == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			b.WriteByte('\'')
+			i++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func (e *Executor) batchInsert(table string, columns []string, rows [][]string, batchSize int) error {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		if err := e.Exec(buildMultiRowInsert(table, columns, rows[start:end])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func buildMultiRowInsert(table string, columns []string, rows [][]string) string {
+	tuples := make([]string, 0, len(rows))
+	for _, row := range rows {
+		tuples = append(tuples, "("+strings.Join(row, ", ")+")")
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", table, strings.Join(columns, ", "), strings.Join(tuples, ", "))
+}
+
+// GenerateDMLInsertRows generates n rows of literal values for table
+// using the same column generators as GenerateDMLInsertByTable, for
+// callers (such as Pivot.prepare) that want to hand a batch of rows to
+// BulkInsert instead of executing one INSERT per row.
+func (e *Executor) GenerateDMLInsertRows(table *model.TableInfo, n int) (columns []string, rows [][]string, err error) {
+	rows = make([][]string, 0, n)
+	for i := 0; i < n; i++ {
+		cols, values, genErr := e.generateInsertRowValues(table)
+		if genErr != nil {
+			return nil, nil, genErr
+		}
+		columns = cols
+		rows = append(rows, values)
+	}
+	return columns, rows, nil
+}
+
+// generateInsertRowValues produces one row's columns/values by reusing
+// GenerateDMLInsertByTable's own single-row INSERT statement and pulling
+// its column list and value tuple back apart, rather than duplicating
+// its column-generation logic here.
+func (e *Executor) generateInsertRowValues(table *model.TableInfo) (columns []string, values []string, err error) {
+	stmt, err := e.GenerateDMLInsertByTable(table)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseInsertValues(stmt.SQLStmt)
+}
+
+// parseInsertValues splits a single-row "INSERT INTO t (c1, c2) VALUES
+// (v1, v2)" statement back into its column list and literal value
+// tuple.
+func parseInsertValues(stmt string) (columns []string, values []string, err error) {
+	colOpen := strings.Index(stmt, "(")
+	colClose := strings.Index(stmt, ")")
+	if colOpen < 0 || colClose < 0 || colClose < colOpen {
+		return nil, nil, fmt.Errorf("executor: cannot parse columns from insert statement %q", stmt)
+	}
+	columns = splitSQLList(stmt[colOpen+1 : colClose])
+
+	// Search for VALUES only after the column list's closing paren, so
+	// a table/column name that merely contains "values" as a substring
+	// (e.g. a generated table called values_tbl) can't be mistaken for
+	// the keyword.
+	valuesAt := strings.Index(strings.ToUpper(stmt[colClose:]), "VALUES")
+	if valuesAt < 0 {
+		return nil, nil, fmt.Errorf("executor: cannot find VALUES clause in insert statement %q", stmt)
+	}
+	valuesAt += colClose
+	rest := stmt[valuesAt:]
+	valOpen := strings.Index(rest, "(")
+	valClose := strings.LastIndex(rest, ")")
+	if valOpen < 0 || valClose < 0 || valClose < valOpen {
+		return nil, nil, fmt.Errorf("executor: cannot parse values from insert statement %q", stmt)
+	}
+	values = splitSQLList(rest[valOpen+1 : valClose])
+
+	if len(columns) != len(values) {
+		return nil, nil, fmt.Errorf("executor: column/value count mismatch in insert statement %q", stmt)
+	}
+	return columns, values, nil
+}
+
+// splitSQLList splits a comma-separated list of SQL identifiers or
+// literals, respecting single-quoted string literals (both the doubled-
+// quote and MySQL/TiDB's backslash-escaped styles) so a literal
+// containing a comma or an escaped quote isn't split in the middle.
+func splitSQLList(s string) []string {
+	var parts []string
+	var current strings.Builder
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && inQuotes && i+1 < len(s):
+			current.WriteByte(c)
+			i++
+			current.WriteByte(s[i])
+		case c == '\'':
+			inQuotes = !inQuotes
+			current.WriteByte(c)
+		case c == ',' && !inQuotes:
+			parts = append(parts, strings.TrimSpace(current.String()))
+			current.Reset()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	parts = append(parts, strings.TrimSpace(current.String()))
+	return parts
+}
@@ -0,0 +1,95 @@
+package executor
+
+import "testing"
+
+func TestBuildMultiRowInsert(t *testing.T) {
+	got := buildMultiRowInsert("t", []string{"a", "b"}, [][]string{{"1", "'x'"}, {"2", "'y'"}})
+	want := "INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y')"
+	if got != want {
+		t.Errorf("buildMultiRowInsert() = %q, want %q", got, want)
+	}
+}
+
+func TestParseInsertValues(t *testing.T) {
+	cases := []struct {
+		stmt        string
+		wantColumns []string
+		wantValues  []string
+		wantErr     bool
+	}{
+		{
+			stmt:        "INSERT INTO t (a, b) VALUES (1, 'x')",
+			wantColumns: []string{"a", "b"},
+			wantValues:  []string{"1", "'x'"},
+		},
+		{
+			stmt:        "INSERT INTO t (a, b) VALUES (1, 'a, b')",
+			wantColumns: []string{"a", "b"},
+			wantValues:  []string{"1", "'a, b'"},
+		},
+		{
+			stmt:    "INSERT INTO t (a, b) missing values clause",
+			wantErr: true,
+		},
+		{
+			stmt:        "INSERT INTO values_tbl (a, b) VALUES (1, 'x')",
+			wantColumns: []string{"a", "b"},
+			wantValues:  []string{"1", "'x'"},
+		},
+		{
+			stmt:        `INSERT INTO t (a, b, c) VALUES (1, 'it\'s, tricky', 'next')`,
+			wantColumns: []string{"a", "b", "c"},
+			wantValues:  []string{"1", `'it\'s, tricky'`, "'next'"},
+		},
+	}
+	for _, c := range cases {
+		cols, vals, err := parseInsertValues(c.stmt)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("parseInsertValues(%q): expected error, got nil", c.stmt)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseInsertValues(%q): unexpected error: %v", c.stmt, err)
+			continue
+		}
+		if !equalSlices(cols, c.wantColumns) {
+			t.Errorf("parseInsertValues(%q) columns = %v, want %v", c.stmt, cols, c.wantColumns)
+		}
+		if !equalSlices(vals, c.wantValues) {
+			t.Errorf("parseInsertValues(%q) values = %v, want %v", c.stmt, vals, c.wantValues)
+		}
+	}
+}
+
+func TestCopyArg(t *testing.T) {
+	cases := []struct {
+		literal string
+		want    interface{}
+	}{
+		{"NULL", nil},
+		{"null", nil},
+		{"1", "1"},
+		{"'x'", "x"},
+		{`'it\'s, tricky'`, "it's, tricky"},
+		{"'it''s'", "it's"},
+	}
+	for _, c := range cases {
+		if got := copyArg(c.literal); got != c.want {
+			t.Errorf("copyArg(%q) = %#v, want %#v", c.literal, got, c.want)
+		}
+	}
+}
+
+func equalSlices(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
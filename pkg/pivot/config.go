@@ -0,0 +1,66 @@
+package pivot
+
+// Config holds the knobs that control how a Pivot run connects to and
+// exercises the target database.
+type Config struct {
+	Dsn         string
+	PrepareStmt bool
+	Hint        bool
+
+	// Driver selects the SQL dialect to speak. Supported values are
+	// "mysql" (default) and "postgres". When empty, NewPivotWithDriver
+	// infers it from Dsn's URL scheme (see connection.InferDriver).
+	Driver string
+
+	// PrepareRows is the number of rows to seed into each table during
+	// prepare, loaded through Executor.BulkInsert instead of one INSERT
+	// per row. Zero keeps the old single-row-per-table behavior.
+	PrepareRows int
+
+	// BulkBatchSize caps how many rows go into a single multi-row
+	// INSERT statement on engines that fall back to batched inserts
+	// instead of COPY. Ignored on PostgreSQL.
+	BulkBatchSize int
+
+	// Oracle selects which bug-finding strategy progress runs each
+	// iteration: "pqs" (the default, pivoted-row oracle) or "tlp"
+	// (Ternary Logic Partitioning). Empty means "pqs".
+	Oracle string
+
+	// Features gates which optional SELECT constructs GenSelectStmt may
+	// generate, so users can enable them incrementally as bugs are
+	// shaken out of each one. Zero value keeps the original flat
+	// cross-product selects.
+	Features FeatureSet
+
+	// Seed sets the PRNG seed for the whole run, so a failure can be
+	// reproduced later via `wreck-it replay`. Zero means "pick one from
+	// the clock and record it", matching the old unreproducible
+	// behavior.
+	Seed int64
+
+	// CorpusDir, when set, makes every progress iteration write a
+	// CorpusRecord (seed, schema hash, pivot row, generated SQL) as its
+	// own JSON file under this directory, so interesting runs can be
+	// replayed or minimized later.
+	CorpusDir string
+}
+
+// FeatureSet is a bitmask of optional SELECT constructs GenSelectStmt
+// may use.
+type FeatureSet uint32
+
+const (
+	FeatureExplicitJoins FeatureSet = 1 << iota
+	FeatureOuterJoins
+	FeatureGroupBy
+	FeatureOrderBy
+	FeatureLimitOffset
+	FeatureDistinct
+	FeatureSubquery
+)
+
+// Has reports whether every bit in want is set in fs.
+func (fs FeatureSet) Has(want FeatureSet) bool {
+	return fs&want == want
+}
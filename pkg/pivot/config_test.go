@@ -0,0 +1,26 @@
+package pivot
+
+import "testing"
+
+func TestFeatureSetHas(t *testing.T) {
+	fs := FeatureExplicitJoins | FeatureOrderBy
+
+	if !fs.Has(FeatureExplicitJoins) {
+		t.Error("expected FeatureExplicitJoins to be set")
+	}
+	if !fs.Has(FeatureOrderBy) {
+		t.Error("expected FeatureOrderBy to be set")
+	}
+	if fs.Has(FeatureGroupBy) {
+		t.Error("did not expect FeatureGroupBy to be set")
+	}
+	if !fs.Has(FeatureExplicitJoins | FeatureOrderBy) {
+		t.Error("expected both bits together to be reported as set")
+	}
+	if fs.Has(FeatureExplicitJoins | FeatureGroupBy) {
+		t.Error("did not expect a mixed set/unset mask to be reported as set")
+	}
+	if !FeatureSet(0).Has(0) {
+		t.Error("expected the zero value to have the zero mask")
+	}
+}
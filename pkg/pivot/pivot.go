@@ -23,35 +23,51 @@ type Pivot struct {
 	DB       *sql.DB
 	DBName   string
 	Executor *executor.Executor
+	Dialect  connection.Dialect
+	Rand     *rand.Rand
 
 	Generator
 }
 
 func NewPivot(dsn string, DBName string) (*Pivot, error) {
+	return NewPivotWithDriver(dsn, DBName, "")
+}
+
+// NewPivotWithDriver is like NewPivot but lets the caller pick the SQL
+// dialect explicitly instead of inferring it from dsn. driver must be
+// one of the values accepted by connection.DialectFor ("", "mysql",
+// "postgres"); "" infers from dsn's URL scheme via connection.InferDriver.
+func NewPivotWithDriver(dsn string, DBName string, driver string) (*Pivot, error) {
 	e, err := executor.New(dsn, "test")
 	if err != nil {
 		return nil, err
 	}
+	if driver == "" {
+		driver = connection.InferDriver(dsn)
+	}
+	dialect, err := connection.DialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+	// executor.New doesn't take a driver/dialect argument, so the
+	// resolved dialect has to be threaded onto the Executor here -
+	// otherwise BulkInsert's postgres/mysql branch can never see it.
+	e.Dialect = dialect
 	conf := &Config{
 		Dsn:         dsn,
 		PrepareStmt: false,
 		Hint:        false,
+		Driver:      dialect.Name(),
 	}
 	return &Pivot{
 		Conf:      conf,
 		DBName:    DBName,
 		Executor:  e,
+		Dialect:   dialect,
 		Generator: Generator{},
 	}, nil
 }
 
-const (
-	tableSQL        = "DESC %s.%s"
-	indexSQL        = "SHOW INDEX FROM %s.%s"
-	schemaSQL       = "SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_TYPE FROM information_schema.tables"
-	indexColumnName = "Key_name"
-)
-
 func (p *Pivot) Start(ctx context.Context) {
 	p.cleanup(ctx)
 	p.kickup(ctx)
@@ -64,22 +80,39 @@ func (p *Pivot) Close() {
 
 }
 
+// seedRand picks the run's PRNG seed (Conf.Seed if set, otherwise one
+// derived from the clock, recorded back onto Conf.Seed so it ends up in
+// every CorpusRecord) and seeds p.Rand, the single *rand.Rand every
+// random choice pivot/generator/executor makes for this run must read
+// from - never the process-global math/rand source, which two Pivots
+// seeded differently and run concurrently in the same process would
+// otherwise stomp on.
+func (p *Pivot) seedRand() {
+	if p.Rand != nil {
+		return
+	}
+	if p.Conf.Seed == 0 {
+		p.Conf.Seed = time.Now().UnixNano()
+	}
+	p.Rand = rand.New(rand.NewSource(p.Conf.Seed))
+}
+
 func (p *Pivot) Init(ctx context.Context) {
-	rand.Seed(time.Now().UnixNano())
+	p.seedRand()
 	p.Tables = make([]Table, 0)
 
 	// Warn: Hard code db name
-	tables, err := p.Executor.GetConn().FetchTables(p.DBName)
+	tableNames, err := p.fetchTableNames()
 	if err != nil {
 		panic(err)
 	}
-	for _, i := range tables {
+	for _, i := range tableNames {
 		t := Table{Name: model.NewCIStr(i)}
-		t.Columns, err = p.Executor.GetConn().FetchColumns(p.DBName, i)
+		t.Columns, err = p.fetchColumnNames(i)
 		if err != nil {
 			panic(err)
 		}
-		idx, err := p.Executor.GetConn().FetchIndexes(p.DBName, i)
+		idx, err := p.fetchIndexNames(i)
 		if err != nil {
 			panic(err)
 		}
@@ -90,8 +123,62 @@ func (p *Pivot) Init(ctx context.Context) {
 	}
 }
 
+// fetchTableNames lists the base tables in p.DBName through p.Dialect's
+// information_schema query, the same way ChoosePivotedRow already routes
+// its random-row query through the dialect instead of assuming MySQL.
+func (p *Pivot) fetchTableNames() ([]string, error) {
+	rows, err := p.execSelect(p.Dialect.FetchTablesSQL(p.DBName))
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for _, row := range rows {
+		if len(row) < 3 || row[2].ValString != "BASE TABLE" {
+			continue
+		}
+		tables = append(tables, row[1].ValString)
+	}
+	return tables, nil
+}
+
+// fetchColumnNames describes table through p.Dialect's column-listing
+// query. MySQL's DESC and Postgres's information_schema.columns query
+// both project the column name first, so it's always row[0].
+func (p *Pivot) fetchColumnNames(table string) ([]string, error) {
+	rows, err := p.execSelect(p.Dialect.FetchColumnsSQL(p.DBName, table))
+	if err != nil {
+		return nil, err
+	}
+	var columns []string
+	for _, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		columns = append(columns, row[0].ValString)
+	}
+	return columns, nil
+}
+
+// fetchIndexNames enumerates the indexes on table through p.Dialect's
+// index-listing query, which on both engines projects only the index
+// name (see Dialect.IndexNameColumn), so it's always row[0].
+func (p *Pivot) fetchIndexNames(table string) ([]string, error) {
+	rows, err := p.execSelect(p.Dialect.FetchIndexesSQL(p.DBName, table))
+	if err != nil {
+		return nil, err
+	}
+	var indexes []string
+	for _, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		indexes = append(indexes, row[0].ValString)
+	}
+	return indexes, nil
+}
+
 func (p *Pivot) prepare(ctx context.Context) {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	r := p.Rand
 	for i := 0; i < r.Intn(10)+1; i++ {
 		sql, _ := p.Executor.GenerateDDLCreateTable()
 		err := p.Executor.Exec(sql.SQLStmt)
@@ -118,10 +205,21 @@ func (p *Pivot) prepare(ctx context.Context) {
 	}
 
 	for _, table := range p.Executor.GetTables() {
-		sql, _ := p.Executor.GenerateDMLInsertByTable(table.Table)
-		err = p.Executor.Exec(sql.SQLStmt)
-		if err != nil {
-			log.L().Error("insert data failed", zap.String("sql", sql.SQLStmt), zap.Error(err))
+		if p.Conf.PrepareRows <= 0 {
+			sql, _ := p.Executor.GenerateDMLInsertByTable(table.Table)
+			err = p.Executor.Exec(sql.SQLStmt)
+			if err != nil {
+				log.L().Error("insert data failed", zap.String("sql", sql.SQLStmt), zap.Error(err))
+			}
+			continue
+		}
+		columns, rows, genErr := p.Executor.GenerateDMLInsertRows(table.Table, p.Conf.PrepareRows)
+		if genErr != nil {
+			log.L().Error("generate bulk rows failed", zap.String("table", table.Table.Name.O), zap.Error(genErr))
+			continue
+		}
+		if err = p.Executor.BulkInsert(table.Table.Name.O, columns, rows, p.Conf.BulkBatchSize); err != nil {
+			log.L().Error("bulk insert failed", zap.String("table", table.Table.Name.O), zap.Error(err))
 		}
 	}
 }
@@ -134,6 +232,7 @@ func (p *Pivot) cleanup(ctx context.Context) {
 
 func (p *Pivot) kickup(ctx context.Context) {
 	p.wg.Add(1)
+	p.seedRand()
 	p.prepare(ctx)
 	p.Init(ctx)
 
@@ -154,6 +253,14 @@ func (p *Pivot) kickup(ctx context.Context) {
 }
 
 func (p *Pivot) progress(ctx context.Context) {
+	if p.Conf.Oracle == "tlp" {
+		p.progressTLP(ctx)
+		return
+	}
+	p.progressPQS(ctx)
+}
+
+func (p *Pivot) progressPQS(ctx context.Context) {
 	// rand one pivot row for one table
 	pivotRows, usedTables, err := p.ChoosePivotedRow()
 	if err != nil {
@@ -161,20 +268,22 @@ func (p *Pivot) progress(ctx context.Context) {
 	}
 	// generate sql ast tree and
 	// generate sql where clause
-	selectStmt, columns, err := p.GenSelectStmt(pivotRows, usedTables)
+	selectStmt, columns, nullable, err := p.genSelectStmt(pivotRows, usedTables)
 	if err != nil {
 		panic(err)
 	}
+	p.recordCorpus(pivotRows, columns, selectStmt)
 	// execute sql, ensure not null result set
 	resultRows, err := p.execSelect(selectStmt)
 	if err != nil {
 		panic(err)
 	}
 	// verify pivot row in result row set
-	correct := p.verify(pivotRows, columns, resultRows)
+	correct := p.verify(pivotRows, columns, resultRows, nullable)
 	if !correct {
-		panic(fmt.Sprintf("data verified failed. pivot rows: %v . result rows: %v . query: %s",
-			pivotRows, resultRows, selectStmt,
+		reduced := p.Reducer().Reduce(selectStmt, pivotRows, columns)
+		panic(fmt.Sprintf("data verified failed. pivot rows: %v . result rows: %v . query: %s . minimized: %s",
+			pivotRows, resultRows, selectStmt, reduced.SQL,
 		))
 	}
 	fmt.Printf("run one statment [%s] successfully!\n", selectStmt)
@@ -186,14 +295,14 @@ func (p *Pivot) ChoosePivotedRow() (map[TableColumn]*connection.QueryItem, []Tab
 	result := make(map[TableColumn]*connection.QueryItem)
 	count := 1
 	if len(p.Tables) > 1 {
-		count = Rd(len(p.Tables)-1) + 1
+		count = p.Rand.Intn(len(p.Tables)-1) + 1
 	}
-	rand.Shuffle(len(p.Tables), func(i, j int) { p.Tables[i], p.Tables[j] = p.Tables[j], p.Tables[i] })
+	p.Rand.Shuffle(len(p.Tables), func(i, j int) { p.Tables[i], p.Tables[j] = p.Tables[j], p.Tables[i] })
 	usedTables := p.Tables[:count]
 	var reallyUsed []Table
 
 	for _, i := range usedTables {
-		sql := fmt.Sprintf("SELECT * FROM %s ORDER BY RAND() LIMIT 1;", i.Name)
+		sql := p.Dialect.RandomRowLimit(i.Name.O)
 		exeRes, err := p.execSelect(sql)
 		if err != nil {
 			panic(err)
@@ -212,15 +321,34 @@ func (p *Pivot) ChoosePivotedRow() (map[TableColumn]*connection.QueryItem, []Tab
 }
 
 func (p *Pivot) GenSelectStmt(pivotRows map[TableColumn]*connection.QueryItem, usedTables []Table) (string, []TableColumn, error) {
+	stmt, columns, _, err := p.genSelectStmt(pivotRows, usedTables)
+	return stmt, columns, err
+}
+
+// genSelectStmt is GenSelectStmt's feature-aware core. With Conf.Features
+// at its zero value it keeps the original flat depth-6 cross-product
+// select. Once any bit is set it instead routes through
+// buildFeatureSelect, which actually synthesizes the requested
+// constructs (explicit/outer joins, GROUP BY/HAVING, ORDER BY,
+// LIMIT/OFFSET, DISTINCT, subqueries) rather than just accepting the
+// bitmask. When Features enables outer joins, the returned nullable set
+// lists the TableColumns that come from the non-preserved side of an
+// OUTER JOIN: verify must accept NULL there even when the pivot value
+// itself isn't NULL, since the row simply didn't satisfy the ON
+// predicate.
+func (p *Pivot) genSelectStmt(pivotRows map[TableColumn]*connection.QueryItem, usedTables []Table) (string, []TableColumn, map[TableColumn]bool, error) {
+	if p.Conf.Features != 0 {
+		return p.buildFeatureSelect(usedTables, p.Conf.Features, pivotRows)
+	}
 	stmtAst, err := p.selectStmtAst(6, usedTables)
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
 	}
 	sql, columns, err := p.selectStmt(&stmtAst, usedTables, pivotRows)
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
 	}
-	return sql, columns, nil
+	return sql, columns, nil, nil
 }
 
 func (p *Pivot) ExecAndVerify(stmt string, originRow map[TableColumn]*connection.QueryItem, columns []TableColumn) (bool, error) {
@@ -228,7 +356,7 @@ func (p *Pivot) ExecAndVerify(stmt string, originRow map[TableColumn]*connection
 	if err != nil {
 		return false, err
 	}
-	res := p.verify(originRow, columns, resultSets)
+	res := p.verify(originRow, columns, resultSets, nil)
 	return res, nil
 }
 
@@ -238,7 +366,11 @@ func (p *Pivot) execSelect(stmt string) ([][]*connection.QueryItem, error) {
 }
 
 // TODO implement it
-func (p *Pivot) verify(originRow map[TableColumn]*connection.QueryItem, columns []TableColumn, resultSets [][]*connection.QueryItem) bool {
+// nullable lists the TableColumns allowed to come back NULL even when
+// the pivot value isn't, because they were projected from the
+// non-preserved side of an OUTER JOIN. It is nil outside Features
+// outer-join generation.
+func (p *Pivot) verify(originRow map[TableColumn]*connection.QueryItem, columns []TableColumn, resultSets [][]*connection.QueryItem, nullable map[TableColumn]bool) bool {
 	fmt.Println("=========  ORIGIN ROWS ======")
 	for k, v := range originRow {
 		fmt.Printf("key: %+v, value: [null: %v, value: %s]\n", k, v.Null, v.ValString)
@@ -250,7 +382,7 @@ func (p *Pivot) verify(originRow map[TableColumn]*connection.QueryItem, columns
 	}
 
 	for _, row := range resultSets {
-		if p.checkRow(originRow, columns, row) {
+		if p.checkRow(originRow, columns, row, nullable) {
 			return true
 		}
 	}
@@ -266,9 +398,12 @@ func (p *Pivot) verify(originRow map[TableColumn]*connection.QueryItem, columns
 	return false
 }
 
-func (p *Pivot) checkRow(originRow map[TableColumn]*connection.QueryItem, columns []TableColumn, resultSet []*connection.QueryItem) bool {
+func (p *Pivot) checkRow(originRow map[TableColumn]*connection.QueryItem, columns []TableColumn, resultSet []*connection.QueryItem, nullable map[TableColumn]bool) bool {
 	for i, c := range columns {
 		fmt.Printf("i: %d, column: %+v, left: %+v, right: %+v", i, c, originRow[c], resultSet[i])
+		if nullable[c] && resultSet[i].Null {
+			continue
+		}
 		if !compareQueryItem(originRow[c], resultSet[i]) {
 			return false
 		}
@@ -0,0 +1,419 @@
+package pivot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zhouqiang-cl/wreck-it/pkg/connection"
+)
+
+// Reduction is the outcome of running a Reducer over a failing SELECT:
+// the smallest statement that still reproduces the oracle mismatch,
+// plus a self-contained reproducer script a user can hand to a bug
+// report without shipping their whole random corpus.
+type Reduction struct {
+	SQL      string
+	ReproSQL string
+}
+
+// Reducer runs delta debugging over a query that failed verification,
+// shrinking it clause by clause while re-checking the same failure
+// against the original pivot rows.
+type Reducer struct {
+	p *Pivot
+}
+
+// Reducer returns a Reducer bound to this Pivot's executor/tables.
+func (p *Pivot) Reducer() *Reducer {
+	return &Reducer{p: p}
+}
+
+// Reduce takes a SELECT statement that is known to fail verification
+// against pivotRows/columns and returns the smallest variant of it that
+// still fails, by repeatedly dropping WHERE predicates, JOINed tables
+// and projected columns and keeping each removal only if the failure
+// survives.
+//
+// All splitting below works on the rendered SQL text rather than a
+// parsed AST (selectStmtAst's tree isn't available after selectStmt has
+// rendered it to a string), but it is parenthesis/quote-aware so a
+// predicate, string literal or subquery that happens to contain " AND "
+// or a column list that contains ", " inside parens isn't torn apart
+// mid-subexpression, and whole JOIN clauses (table + its own ON) are
+// dropped atomically, last first, so a removal can never leave a
+// dangling ON referencing a table that's no longer in the FROM list.
+func (r *Reducer) Reduce(stmt string, pivotRows map[TableColumn]*connection.QueryItem, columns []TableColumn) Reduction {
+	if !r.stillFails(stmt, pivotRows, columns) {
+		// Nothing to minimize against: the caller already confirmed a
+		// failure, but re-running it here came back clean (e.g. a
+		// flaky/non-deterministic bug). Report as-is.
+		return Reduction{SQL: stmt, ReproSQL: r.reproScript(stmt, pivotRows, columns)}
+	}
+
+	stmt = r.dropWherePredicates(stmt, pivotRows, columns)
+	stmt = r.dropJoinedTables(stmt, pivotRows, columns)
+	stmt, columns = r.shrinkProjectedColumns(stmt, pivotRows, columns)
+	stmt = r.replaceWithConstants(stmt, pivotRows, columns)
+
+	return Reduction{SQL: stmt, ReproSQL: r.reproScript(stmt, pivotRows, columns)}
+}
+
+// stillFails re-executes candidate against the same pivot rows and
+// reports whether it reproduces the original failure (an error, or a
+// verify mismatch both count).
+func (r *Reducer) stillFails(candidate string, pivotRows map[TableColumn]*connection.QueryItem, columns []TableColumn) bool {
+	ok, err := r.p.ExecAndVerify(candidate, pivotRows, columns)
+	if err != nil {
+		return true
+	}
+	return !ok
+}
+
+// dropWherePredicates tries removing one AND-joined predicate at a time
+// from the WHERE clause, keeping the removal whenever the statement
+// still fails.
+func (r *Reducer) dropWherePredicates(stmt string, pivotRows map[TableColumn]*connection.QueryItem, columns []TableColumn) string {
+	prefix, where, suffix, ok := splitWhere(stmt)
+	if !ok {
+		return stmt
+	}
+	predicates := splitTopLevel(where, " AND ")
+	for i := 0; i < len(predicates); {
+		candidatePredicates := append(append([]string{}, predicates[:i]...), predicates[i+1:]...)
+		if len(candidatePredicates) == 0 {
+			i++
+			continue
+		}
+		candidate := prefix + " WHERE " + strings.Join(candidatePredicates, " AND ") + suffix
+		if r.stillFails(candidate, pivotRows, columns) {
+			predicates = candidatePredicates
+			continue
+		}
+		i++
+	}
+	if len(predicates) == len(splitTopLevel(where, " AND ")) {
+		return stmt
+	}
+	return prefix + " WHERE " + strings.Join(predicates, " AND ") + suffix
+}
+
+// dropJoinedTables tries removing explicit JOIN clauses (each one's
+// keyword, table and own ON predicate together) from the end of the
+// FROM list, last first, keeping each removal while the statement still
+// fails and stopping at the first clause whose removal makes it pass.
+// Only the trailing clause is ever removed, since joinPredicate's ON for
+// each join also references the table joined just before it. The
+// leading table in the FROM list is never dropped.
+func (r *Reducer) dropJoinedTables(stmt string, pivotRows map[TableColumn]*connection.QueryItem, columns []TableColumn) string {
+	prefix, from, suffix, ok := splitFrom(stmt)
+	if !ok {
+		return stmt
+	}
+	clauses := splitJoinClauses(from)
+	for len(clauses) > 1 {
+		candidateClauses := clauses[:len(clauses)-1]
+		candidate := prefix + " FROM " + strings.Join(candidateClauses, " ") + suffix
+		if !r.stillFails(candidate, pivotRows, columns) {
+			break
+		}
+		clauses = candidateClauses
+	}
+	return prefix + " FROM " + strings.Join(clauses, " ") + suffix
+}
+
+// shrinkProjectedColumns tries removing one projected column at a time
+// from the SELECT list, keeping the removal whenever the statement
+// still fails. At least one column is always kept.
+//
+// trackedColumns is kept as a parallel slice to cols, dropped from in
+// lockstep, so stillFails/checkRow always indexes the narrowed result
+// set against the matching subset of columns - not the original,
+// now-too-long columns slice, which would run off the end of a shorter
+// result set (or silently compare the wrong column) as soon as anything
+// but the very last column is dropped. The narrowed columns are
+// returned alongside the statement so later steps use them too.
+func (r *Reducer) shrinkProjectedColumns(stmt string, pivotRows map[TableColumn]*connection.QueryItem, columns []TableColumn) (string, []TableColumn) {
+	prefix, projected, suffix, ok := splitSelectList(stmt)
+	if !ok {
+		return stmt, columns
+	}
+	cols := splitTopLevel(projected, ", ")
+	trackedColumns := append([]TableColumn{}, columns...)
+	for i := 0; i < len(cols); {
+		if len(cols) == 1 {
+			break
+		}
+		candidateCols := append(append([]string{}, cols[:i]...), cols[i+1:]...)
+		candidateColumns := append(append([]TableColumn{}, trackedColumns[:i]...), trackedColumns[i+1:]...)
+		candidate := prefix + strings.Join(candidateCols, ", ") + suffix
+		if r.stillFails(candidate, pivotRows, candidateColumns) {
+			cols = candidateCols
+			trackedColumns = candidateColumns
+			continue
+		}
+		i++
+	}
+	return prefix + strings.Join(cols, ", ") + suffix, trackedColumns
+}
+
+// replaceWithConstants tries replacing each remaining WHERE predicate
+// wholesale with TRUE, keeping the substitution whenever the statement
+// still fails; this collapses predicates whose exact shape doesn't
+// matter to the bug down to the simplest possible form.
+func (r *Reducer) replaceWithConstants(stmt string, pivotRows map[TableColumn]*connection.QueryItem, columns []TableColumn) string {
+	prefix, where, suffix, ok := splitWhere(stmt)
+	if !ok {
+		return stmt
+	}
+	predicates := splitTopLevel(where, " AND ")
+	for i, pred := range predicates {
+		if pred == "TRUE" {
+			continue
+		}
+		candidatePredicates := append([]string{}, predicates...)
+		candidatePredicates[i] = "TRUE"
+		candidate := prefix + " WHERE " + strings.Join(candidatePredicates, " AND ") + suffix
+		if r.stillFails(candidate, pivotRows, columns) {
+			predicates = candidatePredicates
+		}
+	}
+	return prefix + " WHERE " + strings.Join(predicates, " AND ") + suffix
+}
+
+// reproScript renders a self-contained repro: best-effort CREATE TABLE
+// and INSERT statements for just the tables whose columns the minimized
+// query still projects (columns), followed by the query itself. Restricting the
+// schema to referenced tables is also this Reducer's schema-shrinking
+// step: since pivotRows already holds exactly one row per table (there
+// is no larger per-table row set to shrink down), the only shrinkable
+// schema state is the set of unreferenced tables, which this simply
+// never emits.
+//
+// wreck-it's Table/TableColumn types don't carry a SQL column type
+// (only a name), so the CREATE TABLE columns below are declared TEXT;
+// this is enough to replay the query and values faithfully without a
+// live scratch database, but a user chasing a type-specific bug will
+// still need their original schema.
+func (r *Reducer) reproScript(stmt string, pivotRows map[TableColumn]*connection.QueryItem, columns []TableColumn) string {
+	tableColumns := make(map[string][]string)
+	var tableOrder []string
+	seenCol := make(map[TableColumn]bool)
+	for _, c := range columns {
+		if seenCol[c] {
+			continue
+		}
+		seenCol[c] = true
+		if _, ok := tableColumns[c.Table]; !ok {
+			tableOrder = append(tableOrder, c.Table)
+		}
+		tableColumns[c.Table] = append(tableColumns[c.Table], c.Name)
+	}
+	sort.Strings(tableOrder)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- wreck-it reproducer for db %s\n", r.p.DBName)
+	for _, table := range tableOrder {
+		cols := tableColumns[table]
+		defs := make([]string, len(cols))
+		for i, c := range cols {
+			defs[i] = fmt.Sprintf("%s TEXT", c)
+		}
+		fmt.Fprintf(&b, "CREATE TABLE %s (%s);\n", table, strings.Join(defs, ", "))
+
+		values := make([]string, len(cols))
+		for i, c := range cols {
+			values[i] = literalFor(pivotRows[TableColumn{table, c}])
+		}
+		fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES (%s);\n", table, strings.Join(cols, ", "), strings.Join(values, ", "))
+	}
+	fmt.Fprintf(&b, "%s;\n", stmt)
+	return b.String()
+}
+
+// literalFor renders item as a SQL literal for the repro script's
+// INSERT statements, quoting non-NULL values as strings since the
+// repro schema declares every column TEXT.
+func literalFor(item *connection.QueryItem) string {
+	if item == nil || item.Null {
+		return "NULL"
+	}
+	return "'" + strings.ReplaceAll(item.ValString, "'", "''") + "'"
+}
+
+// splitTopLevel splits s on every occurrence of sep that sits outside
+// parentheses and outside a single-quoted string literal, so splitting
+// a WHERE clause on " AND " or a SELECT list on ", " doesn't cut through
+// a subquery, a parenthesized subexpression or a literal that happens
+// to contain the separator.
+func splitTopLevel(s string, sep string) []string {
+	var parts []string
+	depth := 0
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '\'':
+			inQuotes = !inQuotes
+			i++
+		case inQuotes:
+			i++
+		case s[i] == '(':
+			depth++
+			i++
+		case s[i] == ')':
+			depth--
+			i++
+		case depth == 0 && strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[start:i])
+			i += len(sep)
+			start = i
+		default:
+			i++
+		}
+	}
+	parts = append(parts, s[start:])
+	return parts
+}
+
+// joinKeywords are the explicit join forms splitJoinClauses recognizes,
+// longest first so e.g. " LEFT OUTER JOIN " is matched whole instead of
+// splitting at the " JOIN " substring buried inside it.
+var joinKeywords = []string{
+	" LEFT OUTER JOIN ", " RIGHT OUTER JOIN ", " FULL OUTER JOIN ",
+	" LEFT JOIN ", " RIGHT JOIN ", " FULL JOIN ",
+	" INNER JOIN ", " CROSS JOIN ", " JOIN ",
+}
+
+// splitJoinClauses splits a FROM-clause body into its leading table and
+// one chunk per explicit join: "<JOIN KEYWORD> <table> [ON <predicate>]"
+// kept together as a single atomic string. Dropping the last chunk
+// therefore removes a join's table and its own ON predicate as a unit
+// without leaving any later ON clause dangling on the removed table.
+func splitJoinClauses(from string) []string {
+	type match struct {
+		pos int
+		end int
+	}
+	depth := 0
+	inQuotes := false
+	var matches []match
+	for i := 0; i < len(from); {
+		switch {
+		case from[i] == '\'':
+			inQuotes = !inQuotes
+			i++
+		case inQuotes:
+			i++
+		case from[i] == '(':
+			depth++
+			i++
+		case from[i] == ')':
+			depth--
+			i++
+		case depth == 0:
+			matched := false
+			for _, kw := range joinKeywords {
+				if strings.HasPrefix(from[i:], kw) {
+					matches = append(matches, match{i, i + len(kw)})
+					i += len(kw)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	if len(matches) == 0 {
+		return []string{from}
+	}
+	chunks := []string{strings.TrimSpace(from[:matches[0].pos])}
+	for idx, m := range matches {
+		end := len(from)
+		if idx+1 < len(matches) {
+			end = matches[idx+1].pos
+		}
+		chunks = append(chunks, strings.TrimSpace(from[m.pos:end]))
+	}
+	return chunks
+}
+
+// indexTopLevel returns the byte offset of the first occurrence of
+// substr in s that sits outside parentheses and outside a single-quoted
+// string literal, or -1 if there is none. This is what lets
+// splitWhere/splitFrom/splitSelectList find the statement's own
+// WHERE/FROM instead of one buried inside a subquery's parens.
+func indexTopLevel(s string, substr string) int {
+	depth := 0
+	inQuotes := false
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '\'':
+			inQuotes = !inQuotes
+			i++
+		case inQuotes:
+			i++
+		case s[i] == '(':
+			depth++
+			i++
+		case s[i] == ')':
+			depth--
+			i++
+		case depth == 0 && strings.HasPrefix(s[i:], substr):
+			return i
+		default:
+			i++
+		}
+	}
+	return -1
+}
+
+func splitWhere(stmt string) (prefix, where, suffix string, ok bool) {
+	idx := indexTopLevel(stmt, " WHERE ")
+	if idx < 0 {
+		return "", "", "", false
+	}
+	prefix = stmt[:idx]
+	rest := stmt[idx+len(" WHERE "):]
+	end := len(rest)
+	for _, kw := range []string{" GROUP BY ", " ORDER BY ", " LIMIT "} {
+		if i := indexTopLevel(rest, kw); i >= 0 && i < end {
+			end = i
+		}
+	}
+	return prefix, rest[:end], rest[end:], true
+}
+
+// splitFrom extracts the FROM clause body (the table/join list) from
+// stmt, stopping at whichever of WHERE/GROUP BY/ORDER BY/LIMIT comes
+// first.
+func splitFrom(stmt string) (prefix, from, suffix string, ok bool) {
+	idx := indexTopLevel(stmt, " FROM ")
+	if idx < 0 {
+		return "", "", "", false
+	}
+	prefix = stmt[:idx]
+	rest := stmt[idx+len(" FROM "):]
+	end := len(rest)
+	for _, kw := range []string{" WHERE ", " GROUP BY ", " ORDER BY ", " LIMIT "} {
+		if i := indexTopLevel(rest, kw); i >= 0 && i < end {
+			end = i
+		}
+	}
+	return prefix, rest[:end], rest[end:], true
+}
+
+func splitSelectList(stmt string) (prefix, projected, suffix string, ok bool) {
+	if !strings.HasPrefix(stmt, "SELECT ") {
+		return "", "", "", false
+	}
+	idx := indexTopLevel(stmt, " FROM ")
+	if idx < 0 {
+		return "", "", "", false
+	}
+	return "SELECT ", stmt[len("SELECT "):idx], stmt[idx:], true
+}
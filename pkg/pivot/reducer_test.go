@@ -0,0 +1,114 @@
+package pivot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitTopLevel(t *testing.T) {
+	cases := []struct {
+		s, sep string
+		want   []string
+	}{
+		{"a AND b AND c", " AND ", []string{"a", "b", "c"}},
+		{"(a AND b) AND c", " AND ", []string{"(a AND b)", "c"}},
+		{"a.x = 'y AND z' AND b", " AND ", []string{"a.x = 'y AND z'", "b"}},
+		{"a, b, c", ", ", []string{"a", "b", "c"}},
+		{"f(a, b), c", ", ", []string{"f(a, b)", "c"}},
+	}
+	for _, c := range cases {
+		got := splitTopLevel(c.s, c.sep)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("splitTopLevel(%q, %q) = %v, want %v", c.s, c.sep, got, c.want)
+		}
+	}
+}
+
+func TestSplitWhere(t *testing.T) {
+	prefix, where, suffix, ok := splitWhere("SELECT * FROM t WHERE a = 1 AND b = 2 ORDER BY a")
+	if !ok {
+		t.Fatal("splitWhere: expected ok")
+	}
+	if prefix != "SELECT * FROM t" {
+		t.Errorf("prefix = %q", prefix)
+	}
+	if where != "a = 1 AND b = 2" {
+		t.Errorf("where = %q", where)
+	}
+	if suffix != " ORDER BY a" {
+		t.Errorf("suffix = %q", suffix)
+	}
+
+	if _, _, _, ok := splitWhere("SELECT * FROM t"); ok {
+		t.Error("splitWhere: expected not ok when no WHERE clause present")
+	}
+}
+
+func TestSplitWhereSkipsSubqueryClauses(t *testing.T) {
+	stmt := "SELECT t.a, (SELECT y.b FROM y WHERE y.c = t.a LIMIT 1) AS sub FROM t WHERE t.a > 0"
+	prefix, where, suffix, ok := splitWhere(stmt)
+	if !ok {
+		t.Fatal("splitWhere: expected ok")
+	}
+	wantPrefix := "SELECT t.a, (SELECT y.b FROM y WHERE y.c = t.a LIMIT 1) AS sub FROM t"
+	if prefix != wantPrefix {
+		t.Errorf("prefix = %q, want %q", prefix, wantPrefix)
+	}
+	if where != "t.a > 0" {
+		t.Errorf("where = %q, want %q", where, "t.a > 0")
+	}
+	if suffix != "" {
+		t.Errorf("suffix = %q, want empty", suffix)
+	}
+
+	fromPrefix, from, fromSuffix, ok := splitFrom(stmt)
+	if !ok {
+		t.Fatal("splitFrom: expected ok")
+	}
+	if fromPrefix != "SELECT t.a, (SELECT y.b FROM y WHERE y.c = t.a LIMIT 1) AS sub" {
+		t.Errorf("prefix = %q", fromPrefix)
+	}
+	if from != "t" {
+		t.Errorf("from = %q, want %q", from, "t")
+	}
+	if fromSuffix != " WHERE t.a > 0" {
+		t.Errorf("suffix = %q, want %q", fromSuffix, " WHERE t.a > 0")
+	}
+}
+
+func TestSplitSelectList(t *testing.T) {
+	prefix, projected, suffix, ok := splitSelectList("SELECT a, b FROM t WHERE a = 1")
+	if !ok {
+		t.Fatal("splitSelectList: expected ok")
+	}
+	if prefix != "SELECT " || projected != "a, b" || suffix != " FROM t WHERE a = 1" {
+		t.Errorf("got prefix=%q projected=%q suffix=%q", prefix, projected, suffix)
+	}
+
+	if _, _, _, ok := splitSelectList("UPDATE t SET a = 1"); ok {
+		t.Error("splitSelectList: expected not ok for a non-SELECT statement")
+	}
+}
+
+func TestSplitJoinClauses(t *testing.T) {
+	cases := []struct {
+		from string
+		want []string
+	}{
+		{"a", []string{"a"}},
+		{
+			"a JOIN b ON a.x = b.x JOIN c ON b.y = c.y",
+			[]string{"a", "JOIN b ON a.x = b.x", "JOIN c ON b.y = c.y"},
+		},
+		{
+			"a LEFT OUTER JOIN b ON 1=1",
+			[]string{"a", "LEFT OUTER JOIN b ON 1=1"},
+		},
+	}
+	for _, c := range cases {
+		got := splitJoinClauses(c.from)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("splitJoinClauses(%q) = %v, want %v", c.from, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package pivot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zhouqiang-cl/wreck-it/pkg/connection"
+)
+
+// explicitJoinKeywords are the join forms buildFrom picks between once
+// FeatureExplicitJoins and/or FeatureOuterJoins is enabled.
+var explicitJoinKeywords = []string{"INNER JOIN", "LEFT OUTER JOIN", "RIGHT OUTER JOIN", "FULL OUTER JOIN", "CROSS JOIN"}
+
+// buildFeatureSelect synthesizes a SELECT over usedTables honoring
+// whichever Conf.Features bits are set, returning the rendered SQL, the
+// projected columns in SELECT-list order (so verify/checkRow can line
+// up resultSet positionally), and the subset of those columns allowed
+// to come back NULL because they were projected from the
+// non-preserved side of an OUTER JOIN.
+//
+// It always projects every pivot column across usedTables, since
+// dropping one from the SELECT list would make it impossible for
+// verify to confirm the pivot row is present.
+func (p *Pivot) buildFeatureSelect(usedTables []Table, features FeatureSet, pivotRows map[TableColumn]*connection.QueryItem) (stmt string, columns []TableColumn, nullable map[TableColumn]bool, err error) {
+	for _, t := range usedTables {
+		for _, c := range t.Columns {
+			columns = append(columns, TableColumn{t.Name.O, c})
+		}
+	}
+	if len(columns) == 0 {
+		return "", nil, nil, fmt.Errorf("pivot: no columns available to project over %v", usedTables)
+	}
+
+	selectList := make([]string, len(columns))
+	for i, c := range columns {
+		selectList[i] = fmt.Sprintf("%s.%s", c.Table, c.Name)
+	}
+
+	from, nullable := p.buildFrom(usedTables, features, pivotRows)
+
+	var b strings.Builder
+	b.WriteString("SELECT ")
+	if features.Has(FeatureDistinct) {
+		b.WriteString("DISTINCT ")
+	}
+	b.WriteString(strings.Join(selectList, ", "))
+	b.WriteString(" FROM ")
+	b.WriteString(from)
+
+	if features.Has(FeatureSubquery) {
+		b.WriteString(" WHERE ")
+		b.WriteString(p.randomTautologicalSubquery(usedTables))
+	}
+
+	if features.Has(FeatureGroupBy) {
+		// Grouping by every projected column can only coalesce exact
+		// duplicate rows, never drop or alter the pivot row, so this
+		// stays pivot-safe without a dedicated verify path.
+		b.WriteString(" GROUP BY ")
+		b.WriteString(strings.Join(selectList, ", "))
+		b.WriteString(" HAVING COUNT(*) >= 1")
+	}
+
+	if features.Has(FeatureOrderBy) {
+		b.WriteString(" ORDER BY ")
+		b.WriteString(p.randomOrderBy(selectList))
+	}
+
+	if features.Has(FeatureLimitOffset) {
+		// A genuinely small LIMIT risks cutting the pivot row out of the
+		// result before the generator can prove its rank, so the cap is
+		// kept generous (and OFFSET at 0) until rank-aware limiting
+		// lands; this still exercises LIMIT/OFFSET generation without
+		// making verify unsound.
+		fmt.Fprintf(&b, " LIMIT %d OFFSET 0", 1000000+p.Rand.Intn(1000000))
+	}
+
+	return b.String(), columns, nullable, nil
+}
+
+// buildFrom renders the FROM clause for usedTables. With no join
+// features enabled it keeps the original implicit cross-product join
+// ("FROM a, b, c"). Once FeatureExplicitJoins/FeatureOuterJoins is set,
+// it instead chains the tables with an explicit join keyword per pair.
+//
+// Each ON predicate is built by joinPredicate from pivotRows, not a flat
+// "1=1": it's true for the pivot row (so INNER/CROSS JOIN never lose it)
+// but false or NULL for most other row combinations, so LEFT/RIGHT/FULL
+// OUTER JOIN actually exclude rows sometimes, exercising the nullable
+// set/checkRow's NULL-tolerant comparison instead of leaving it dead
+// code no generated query could ever reach.
+func (p *Pivot) buildFrom(usedTables []Table, features FeatureSet, pivotRows map[TableColumn]*connection.QueryItem) (string, map[TableColumn]bool) {
+	if len(usedTables) < 2 || !(features.Has(FeatureExplicitJoins) || features.Has(FeatureOuterJoins)) {
+		names := make([]string, len(usedTables))
+		for i, t := range usedTables {
+			names[i] = t.Name.O
+		}
+		return strings.Join(names, ", "), nil
+	}
+
+	var candidates []string
+	if features.Has(FeatureExplicitJoins) {
+		candidates = append(candidates, "INNER JOIN", "CROSS JOIN")
+	}
+	if features.Has(FeatureOuterJoins) {
+		candidates = append(candidates, "LEFT OUTER JOIN", "RIGHT OUTER JOIN", "FULL OUTER JOIN")
+	}
+
+	nullable := make(map[TableColumn]bool)
+	var b strings.Builder
+	b.WriteString(usedTables[0].Name.O)
+	seenSoFar := []Table{usedTables[0]}
+	prev := usedTables[0]
+
+	for _, t := range usedTables[1:] {
+		join := candidates[p.Rand.Intn(len(candidates))]
+		b.WriteString(" ")
+		b.WriteString(join)
+		b.WriteString(" ")
+		b.WriteString(t.Name.O)
+		if join != "CROSS JOIN" {
+			b.WriteString(" ON ")
+			b.WriteString(joinPredicate(prev, t, pivotRows, p.Dialect))
+		}
+
+		switch join {
+		case "LEFT OUTER JOIN":
+			markNullable(nullable, t)
+		case "RIGHT OUTER JOIN":
+			markNullable(nullable, seenSoFar...)
+		case "FULL OUTER JOIN":
+			markNullable(nullable, seenSoFar...)
+			markNullable(nullable, t)
+		}
+		seenSoFar = append(seenSoFar, t)
+		prev = t
+	}
+
+	if len(nullable) == 0 {
+		return b.String(), nil
+	}
+	return b.String(), nullable
+}
+
+// joinPredicate builds an ON predicate for joining t onto prev (the
+// table immediately preceding it in the explicit join chain) that the
+// pivot row always satisfies, so it can never drop the pivot row out of
+// an INNER/CROSS JOIN, while still sometimes failing for other row
+// combinations on EITHER side. Anchoring only to t (as a first pass of
+// this fix did) left LEFT OUTER JOIN's ON clause independent of the
+// left-hand input: since every left row would then see the very same
+// set of matching t rows, none would ever fail to find a match and the
+// NULL-padding path stayed unreachable for that join kind. ANDing in a
+// matching pivot-column check on prev as well makes the predicate fail
+// whenever either side's pinned column (see pivotColumnEquals) differs
+// from its pivot value, so LEFT, RIGHT and FULL OUTER JOIN can all pad
+// rows with NULL.
+//
+// Falls back to whichever side has a recorded pivot column (or "1=1"
+// if neither does) when the other side's table has no pivotRows entry
+// for any of its columns - which ChoosePivotedRow's invariant that
+// every usedTables entry has every column recorded in pivotRows means
+// never actually happens on the one call site this feeds today.
+func joinPredicate(prev, t Table, pivotRows map[TableColumn]*connection.QueryItem, dialect connection.Dialect) string {
+	rightPred, rightOK := pivotColumnEquals(t, pivotRows, dialect)
+	leftPred, leftOK := pivotColumnEquals(prev, pivotRows, dialect)
+	switch {
+	case rightOK && leftOK:
+		return fmt.Sprintf("(%s AND %s)", leftPred, rightPred)
+	case rightOK:
+		return rightPred
+	case leftOK:
+		return leftPred
+	default:
+		return "1=1"
+	}
+}
+
+// pivotColumnEquals renders a predicate pinning one column of t to its
+// recorded pivot value - an equality check through dialect.LiteralEquals
+// if that value is non-NULL, an IS NULL check if it is (plain equality
+// against NULL is itself NULL, which would make even the pivot row fail
+// its own join condition). ok is false if pivotRows has nothing recorded
+// for any column of t.
+func pivotColumnEquals(t Table, pivotRows map[TableColumn]*connection.QueryItem, dialect connection.Dialect) (pred string, ok bool) {
+	for _, col := range t.Columns {
+		item, ok := pivotRows[TableColumn{t.Name.O, col}]
+		if !ok {
+			continue
+		}
+		ref := fmt.Sprintf("%s.%s", t.Name.O, col)
+		if item.Null {
+			return ref + " IS NULL", true
+		}
+		return dialect.LiteralEquals(ref, literalFor(item)), true
+	}
+	return "", false
+}
+
+func markNullable(nullable map[TableColumn]bool, tables ...Table) {
+	for _, t := range tables {
+		for _, c := range t.Columns {
+			nullable[TableColumn{t.Name.O, c}] = true
+		}
+	}
+}
+
+// randomTautologicalSubquery builds a `(col IN (SELECT col FROM table)
+// OR col IS NULL)` predicate over one randomly chosen column: since the
+// subquery draws from the same table and column, every row's own
+// non-NULL value is always a member of that set, and the IS NULL arm
+// keeps rows whose value is NULL (for which IN alone is NULL), so this
+// narrows nothing and stays pivot-safe while still exercising
+// subquery-in-WHERE generation.
+func (p *Pivot) randomTautologicalSubquery(usedTables []Table) string {
+	t := usedTables[p.Rand.Intn(len(usedTables))]
+	if len(t.Columns) == 0 {
+		return "1 = 1"
+	}
+	col := t.Columns[p.Rand.Intn(len(t.Columns))]
+	ref := fmt.Sprintf("%s.%s", t.Name.O, col)
+	return fmt.Sprintf("(%s IN (SELECT %s FROM %s) OR %s IS NULL)", ref, col, t.Name.O, ref)
+}
+
+// randomOrderBy renders an ORDER BY list over selectList with a random
+// ASC/DESC and NULL placement per column, rendered through p.Dialect so
+// the NULLS FIRST/LAST behavior is expressed in whatever syntax the
+// target engine actually accepts (MySQL/TiDB has no NULLS FIRST/LAST).
+// Sorting never drops or duplicates a row, so it's pivot-safe regardless
+// of which column or direction is picked.
+func (p *Pivot) randomOrderBy(selectList []string) string {
+	dirs := []string{"ASC", "DESC"}
+	parts := make([]string, len(selectList))
+	for i, col := range selectList {
+		dir := dirs[p.Rand.Intn(len(dirs))]
+		nullsFirst := p.Rand.Intn(2) == 0
+		parts[i] = p.Dialect.OrderByTerm(col, dir, nullsFirst)
+	}
+	return strings.Join(parts, ", ")
+}
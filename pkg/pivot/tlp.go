@@ -0,0 +1,166 @@
+package pivot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+
+	"github.com/zhouqiang-cl/wreck-it/pkg/connection"
+)
+
+// progressTLP runs one iteration of the TLP (Ternary Logic
+// Partitioning) oracle: Q0 is an unconditional select over a random set
+// of tables, Q1/Q2/Q3 partition the same rows by a predicate p, NOT p
+// and p IS NULL. Three-valued SQL logic guarantees every row of Q0
+// lands in exactly one partition, so multiset(Q0) must equal
+// multiset(Q1 u Q2 u Q3); any mismatch is a bug.
+func (p *Pivot) progressTLP(ctx context.Context) {
+	usedTables := p.pickTables()
+
+	base, orderBy, predicate, columns, err := p.genTLPQueries(usedTables)
+	if err != nil {
+		panic(err)
+	}
+	q0 := fmt.Sprintf("%s ORDER BY %s", base, orderBy)
+	// TLP has no single pivoted row to record, so pivotRows is left nil
+	// and only Q0 is recorded, not the predicate or its partitions.
+	// Replay and Minimize check a record against its pivot rows the PQS
+	// way; they do not re-run the TLP partition check for such a record.
+	p.recordCorpus(nil, columns, q0)
+
+	baseRows, err := p.execSelect(q0)
+	if err != nil {
+		panic(err)
+	}
+
+	partitioned, err := p.execTLPPartitions(base, orderBy, predicate)
+	if err != nil {
+		panic(err)
+	}
+
+	if !multisetEqual(baseRows, partitioned) {
+		panic(fmt.Sprintf(
+			"tlp verify failed. query: %s . predicate: %s . base rows: %d . partitioned rows: %d",
+			q0, predicate, len(baseRows), len(partitioned),
+		))
+	}
+
+	fmt.Printf("run one tlp statement [%s] successfully!\n", q0)
+	log.Info("run one tlp statement successfully!", zap.String("query", q0), zap.Int("columns", len(columns)))
+}
+
+// pickTables chooses a random non-empty subset of p.Tables the same way
+// ChoosePivotedRow does, without needing an existing row in any of them
+// (TLP's Q0 has no predicate, so empty tables are fine).
+func (p *Pivot) pickTables() []Table {
+	count := 1
+	if len(p.Tables) > 1 {
+		count = p.Rand.Intn(len(p.Tables)-1) + 1
+	}
+	p.Rand.Shuffle(len(p.Tables), func(i, j int) { p.Tables[i], p.Tables[j] = p.Tables[j], p.Tables[i] })
+	return p.Tables[:count]
+}
+
+// genTLPQueries builds the unordered base clause "SELECT <cols> FROM
+// <tables>" (no ORDER BY, so WHERE can still be appended to it), the
+// ORDER BY column list used to give Q0 and every partition a stable
+// ordering, and a side-effect-free boolean predicate over those tables'
+// columns to partition the base with.
+func (p *Pivot) genTLPQueries(tables []Table) (base string, orderBy string, predicate string, columns []TableColumn, err error) {
+	var projected []string
+	for _, t := range tables {
+		for _, c := range t.Columns {
+			columns = append(columns, TableColumn{t.Name.O, c})
+			projected = append(projected, fmt.Sprintf("%s.%s", t.Name.O, c))
+		}
+	}
+	if len(projected) == 0 {
+		return "", "", "", nil, fmt.Errorf("tlp: no columns available to project over %v", tables)
+	}
+
+	var tableNames []string
+	for _, t := range tables {
+		tableNames = append(tableNames, t.Name.O)
+	}
+
+	predicate = p.randomPredicate(tables)
+	base = fmt.Sprintf("SELECT %s FROM %s", strings.Join(projected, ", "), strings.Join(tableNames, ", "))
+	orderBy = strings.Join(projected, ", ")
+	return base, orderBy, predicate, columns, nil
+}
+
+// randomPredicate picks a column at random and builds a simple, cheap
+// comparison over it. It intentionally avoids any non-deterministic or
+// side-effecting expressions (RAND(), subqueries with side effects,
+// ...) since TLP requires p to evaluate identically across Q0/Q1/Q2/Q3.
+//
+// Table.Columns only carries column names, not SQL types, so the
+// comparison is built through p.Dialect.TernaryPredicate rather than a
+// hardcoded "> 0": that comparison is well-typed for any column type,
+// where a flat "> 0" is a planning-time error on engines (Postgres)
+// that don't coerce text columns to numeric.
+func (p *Pivot) randomPredicate(tables []Table) string {
+	t := tables[p.Rand.Intn(len(tables))]
+	if len(t.Columns) == 0 {
+		return "1 = 1"
+	}
+	col := t.Columns[p.Rand.Intn(len(t.Columns))]
+	return p.Dialect.TernaryPredicate(fmt.Sprintf("%s.%s", t.Name.O, col))
+}
+
+// execTLPPartitions runs Q1/Q2/Q3 and returns their rows concatenated,
+// matching the shape multisetEqual expects to compare against Q0. Each
+// partition appends its WHERE clause to the unordered base before
+// ORDER BY, since WHERE must precede ORDER BY in valid SQL.
+func (p *Pivot) execTLPPartitions(base string, orderBy string, predicate string) ([][]*connection.QueryItem, error) {
+	partitions := []string{
+		fmt.Sprintf("%s WHERE %s ORDER BY %s", base, predicate, orderBy),
+		fmt.Sprintf("%s WHERE NOT (%s) ORDER BY %s", base, predicate, orderBy),
+		fmt.Sprintf("%s WHERE (%s) IS NULL ORDER BY %s", base, predicate, orderBy),
+	}
+	var all [][]*connection.QueryItem
+	for _, stmt := range partitions {
+		rows, err := p.execSelect(stmt)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, rows...)
+	}
+	return all, nil
+}
+
+// multisetEqual compares two result sets as multisets (duplicates
+// matter), since TLP's partitions can reorder rows but must never drop
+// or duplicate one relative to the unconditional query.
+func multisetEqual(a, b [][]*connection.QueryItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int, len(a))
+	for _, row := range a {
+		counts[rowKey(row)]++
+	}
+	for _, row := range b {
+		key := rowKey(row)
+		if counts[key] == 0 {
+			return false
+		}
+		counts[key]--
+	}
+	return true
+}
+
+func rowKey(row []*connection.QueryItem) string {
+	parts := make([]string, len(row))
+	for i, c := range row {
+		if c.Null {
+			parts[i] = "<NULL>"
+			continue
+		}
+		parts[i] = c.ValString
+	}
+	return strings.Join(parts, "\x1f")
+}
@@ -0,0 +1,45 @@
+package pivot
+
+import (
+	"testing"
+
+	"github.com/zhouqiang-cl/wreck-it/pkg/connection"
+)
+
+func item(null bool, val string) *connection.QueryItem {
+	return &connection.QueryItem{Null: null, ValString: val}
+}
+
+func TestRowKey(t *testing.T) {
+	a := rowKey([]*connection.QueryItem{item(false, "1"), item(true, "")})
+	b := rowKey([]*connection.QueryItem{item(false, "1"), item(true, "")})
+	c := rowKey([]*connection.QueryItem{item(false, "2"), item(true, "")})
+	if a != b {
+		t.Errorf("rowKey of identical rows differs: %q vs %q", a, b)
+	}
+	if a == c {
+		t.Errorf("rowKey of different rows matched: %q", a)
+	}
+}
+
+func TestMultisetEqual(t *testing.T) {
+	row1 := []*connection.QueryItem{item(false, "1")}
+	row2 := []*connection.QueryItem{item(false, "2")}
+
+	cases := []struct {
+		name string
+		a, b [][]*connection.QueryItem
+		want bool
+	}{
+		{"equal single rows", [][]*connection.QueryItem{row1}, [][]*connection.QueryItem{row1}, true},
+		{"different length", [][]*connection.QueryItem{row1}, [][]*connection.QueryItem{row1, row2}, false},
+		{"different multiset", [][]*connection.QueryItem{row1, row1}, [][]*connection.QueryItem{row1, row2}, false},
+		{"duplicates matter, equal", [][]*connection.QueryItem{row1, row1}, [][]*connection.QueryItem{row1, row1}, true},
+		{"order independent", [][]*connection.QueryItem{row1, row2}, [][]*connection.QueryItem{row2, row1}, true},
+	}
+	for _, c := range cases {
+		if got := multisetEqual(c.a, c.b); got != c.want {
+			t.Errorf("%s: multisetEqual() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}